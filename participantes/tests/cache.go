@@ -0,0 +1,238 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subsistema de cache de respostas do LLM. runBatch invoca o modelo duas
+// vezes por linha e reprocessa tanto o CSV "pre" quanto o "pos", então os
+// mesmos intents acabam reclassificados repetidamente a custo monetário
+// real. O Cache evita essa repetição guardando a resposta crua do modelo
+// por (model, system-prompt, intent normalizado).
+
+// Cache é a interface comum às implementações de cache de respostas do
+// LLM. Get devolve (valor, true) em caso de acerto; Set grava ou substitui
+// uma entrada.
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key, value string)
+}
+
+// normalizeIntent reduz um intent a uma forma canônica antes do hashing,
+// para que variações triviais de formatação (caixa, espaços) ainda
+// resultem na mesma chave de cache. Sem golang.org/x/text disponível (ver
+// nota em backend.go), aplicamos lowercase + colapso de espaços, que cobre
+// a grande maioria dos casos práticos em vez da normalização NFC completa.
+func normalizeIntent(intent string) string {
+	fields := strings.Fields(strings.ToLower(intent))
+	return strings.Join(fields, " ")
+}
+
+// cacheKey deriva a chave de cache de (model, system prompt, intent),
+// como o SHA-256 hex de "model|systemPromptHash|intentNormalizado".
+func cacheKey(model, systemPrompt, intent string) string {
+	sysHash := sha256.Sum256([]byte(systemPrompt))
+	raw := model + "|" + hex.EncodeToString(sysHash[:]) + "|" + normalizeIntent(intent)
+	key := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(key[:])
+}
+
+// ===== Cache em memória (LRU) =====
+
+// lruCache é um cache LRU simples e thread-safe: ao atingir a capacidade,
+// a entrada menos recentemente usada é descartada.
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+func newLRUCache(capacity int, ttl time.Duration) *lruCache {
+	if capacity <= 0 {
+		capacity = 10_000
+	}
+	return &lruCache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+func (c *lruCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*lruEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return "", false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *lruCache) Set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).value = value
+		el.Value.(*lruEntry).expires = expires
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	c.items[key] = el
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*lruEntry).key)
+		}
+	}
+}
+
+// ===== Cache em disco =====
+
+// diskCache grava cada entrada como um arquivo JSON individual sob um
+// diretório base (por padrão ~/.cache/intent-bench/), permitindo reuso
+// entre execuções do benchmark.
+type diskCache struct {
+	mu  sync.Mutex
+	dir string
+	ttl time.Duration
+}
+
+type diskEntry struct {
+	Value    string    `json:"value"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+func defaultCacheDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".cache", "intent-bench")
+}
+
+func newDiskCache(dir string, ttl time.Duration) (*diskCache, error) {
+	if dir == "" {
+		dir = defaultCacheDir()
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("criar diretório de cache %s: %w", dir, err)
+	}
+	return &diskCache{dir: dir, ttl: ttl}, nil
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *diskCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return "", false
+	}
+	if c.ttl > 0 && time.Since(entry.StoredAt) > c.ttl {
+		_ = os.Remove(c.path(key))
+		return "", false
+	}
+	return entry.Value, true
+}
+
+func (c *diskCache) Set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := diskEntry{Value: value, StoredAt: time.Now()}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(c.path(key), data, 0o644)
+}
+
+// ===== Estatísticas de cache =====
+
+// cacheStats acumula acertos/erros de cache ao longo de uma rodada, para
+// que o relatório final exponha a taxa de hit/miss.
+type cacheStats struct {
+	mu     sync.Mutex
+	Hits   int
+	Misses int
+}
+
+func (s *cacheStats) recordHit() {
+	s.mu.Lock()
+	s.Hits++
+	s.mu.Unlock()
+}
+
+func (s *cacheStats) recordMiss() {
+	s.mu.Lock()
+	s.Misses++
+	s.mu.Unlock()
+}
+
+func (s *cacheStats) hitRate() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total) * 100.0
+}
+
+// newCache constrói o Cache configurado por -cache (off|mem|disk). Um
+// kind desconhecido ou "off" resulta em nil (cache desligado).
+func newCache(kind, diskDir string, ttl time.Duration) (Cache, error) {
+	switch kind {
+	case "", "off":
+		return nil, nil
+	case "mem":
+		return newLRUCache(10_000, ttl), nil
+	case "disk":
+		return newDiskCache(diskDir, ttl)
+	default:
+		return nil, fmt.Errorf("-cache inválido: %q (use off, mem ou disk)", kind)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Classifier unifica os diferentes backends de classificação de intenção
+// (OpenRouter, um endpoint OpenAI-compatível, Ollama local, o endpoint
+// /api/find-service e o classificador determinístico por regras) atrás de
+// uma única interface, para que -backend troque de mecanismo sem duplicar
+// o laço de CSV, o worker pool ou as métricas.
+type Classifier interface {
+	// Classify devolve o ID do serviço escolhido, a resposta crua do
+	// backend (útil para depuração/relatório) e um erro em caso de falha.
+	Classify(ctx context.Context, intent string) (serviceID int, raw string, err error)
+}
+
+// ===== Backend: local (/api/find-service) =====
+
+// localClassifier delega para o endpoint /api/find-service, reaproveitando
+// classifyIntent (com retry/backoff) em vez de duplicar a chamada.
+type localClassifier struct {
+	client   *http.Client
+	endpoint string
+}
+
+func newLocalClassifier(client *http.Client, endpoint string) *localClassifier {
+	return &localClassifier{client: client, endpoint: endpoint}
+}
+
+func (c *localClassifier) Classify(ctx context.Context, intent string) (int, string, error) {
+	outcome := classifyIntent(ctx, c.client, c.endpoint, intent)
+	if outcome.Success != "true" {
+		return 0, "", errors.New(outcome.Error)
+	}
+	id, err := strconv.Atoi(outcome.ServiceID)
+	if err != nil {
+		return 0, outcome.ServiceID, fmt.Errorf("service_id inválido: %w", err)
+	}
+	return id, outcome.ServiceName, nil
+}
+
+// ===== Backend: OpenRouter chat-completions =====
+
+// openRouterClassifier é a versão "Classifier" do caminho já usado por
+// runBatch, sem call#2 nem votação — apenas uma chamada por intent,
+// opcionalmente servida pelo Cache.
+type openRouterClassifier struct {
+	apiKey       string
+	model        string
+	systemPrompt string
+	cache        Cache
+	cstats       *cacheStats
+}
+
+func newOpenRouterClassifier(apiKey, model string, cache Cache, cstats *cacheStats) *openRouterClassifier {
+	return &openRouterClassifier{apiKey: apiKey, model: model, systemPrompt: buildSystemPrompt(), cache: cache, cstats: cstats}
+}
+
+func (c *openRouterClassifier) Classify(ctx context.Context, intent string) (int, string, error) {
+	raw, _, err := cachedCall(ctx, c.cache, c.cstats, false, c.apiKey, c.model, c.systemPrompt, intent, 0)
+	if err != nil {
+		return 0, raw, err
+	}
+	id, err := parseID(raw)
+	return id, raw, err
+}
+
+// ===== Backend: OpenAI-compatível (base URL e header de auth configuráveis) =====
+
+// openAIClassifier fala o mesmo dialeto de chat-completions do OpenRouter,
+// mas contra uma base URL e um header de autenticação arbitrários, para
+// cobrir a própria API da OpenAI ou qualquer proxy compatível.
+type openAIClassifier struct {
+	baseURL      string
+	authHeader   string
+	apiKey       string
+	model        string
+	systemPrompt string
+}
+
+func newOpenAIClassifier(baseURL, authHeader, apiKey, model string) *openAIClassifier {
+	return &openAIClassifier{baseURL: baseURL, authHeader: authHeader, apiKey: apiKey, model: model, systemPrompt: buildSystemPrompt()}
+}
+
+// Classify aplica a mesma política de retry/backoff (defaultRetryConfig)
+// das demais chamadas de rede do pipeline (callWithRetry, embedTexts):
+// erros transitórios de um proxy OpenAI-compatível não devem derrubar a
+// linha na primeira tentativa.
+func (c *openAIClassifier) Classify(ctx context.Context, intent string) (int, string, error) {
+	bodyReq := chatRequest{
+		Model: c.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: c.systemPrompt},
+			{Role: "user", Content: buildUserPrompt(intent)},
+		},
+		MaxTokens: 8,
+	}
+	payload, err := json.Marshal(bodyReq)
+	if err != nil {
+		return 0, "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	var cr chatResponse
+	err = withRetry(ctx, defaultRetryConfig(), func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("new request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(c.authHeader, "Bearer "+c.apiKey)
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("do request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBytes, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return &httpStatusError{Code: resp.StatusCode, Body: string(respBytes)}
+		}
+
+		cr = chatResponse{}
+		if err := json.Unmarshal(respBytes, &cr); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	if len(cr.Choices) == 0 {
+		return 0, "", errors.New("no choices")
+	}
+	raw := strings.TrimSpace(cr.Choices[0].Message.Content)
+	id, err := parseID(raw)
+	return id, raw, err
+}
+
+// ===== Backend: Ollama local (/api/generate) =====
+
+type ollamaRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	System string `json:"system,omitempty"`
+	Stream bool   `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Response string `json:"response"`
+}
+
+// ollamaClassifier roda inteiramente contra um servidor Ollama local, sem
+// nenhuma chamada de rede externa — útil para benchmarks offline ou sem
+// custo por token.
+type ollamaClassifier struct {
+	baseURL      string
+	model        string
+	systemPrompt string
+}
+
+func newOllamaClassifier(baseURL, model string) *ollamaClassifier {
+	return &ollamaClassifier{baseURL: baseURL, model: model, systemPrompt: buildSystemPrompt()}
+}
+
+// Classify aplica a mesma política de retry/backoff (defaultRetryConfig)
+// das demais chamadas de rede do pipeline (callWithRetry, embedTexts):
+// um 5xx ou timeout transitório do servidor Ollama local não deve
+// derrubar a linha na primeira tentativa.
+func (c *ollamaClassifier) Classify(ctx context.Context, intent string) (int, string, error) {
+	bodyReq := ollamaRequest{Model: c.model, Prompt: buildUserPrompt(intent), System: c.systemPrompt, Stream: false}
+	payload, err := json.Marshal(bodyReq)
+	if err != nil {
+		return 0, "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	var or ollamaResponse
+	err = withRetry(ctx, defaultRetryConfig(), func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/generate", bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("new request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("do request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBytes, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return &httpStatusError{Code: resp.StatusCode, Body: string(respBytes)}
+		}
+
+		or = ollamaResponse{}
+		if err := json.Unmarshal(respBytes, &or); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	raw := strings.TrimSpace(or.Response)
+	id, err := parseID(raw)
+	return id, raw, err
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Classificador de fallback por embeddings, usado pelo modo -mode embed e
+// pelo roteamento híbrido -mode hybrid: compara o embedding do intent
+// contra um centróide por serviço, calculado a partir do nome do serviço
+// cadastrado e, opcionalmente, de exemplos de treino carregados do CSV
+// "pre" (intents_pre_loaded.csv). Em modo hybrid, um top-1 com margem
+// suficiente sobre o top-2 decide direto; caso contrário, os top-3
+// candidatos são injetados no system prompt como shortlist restrita para
+// o LLM.
+//
+// Sem runtime ONNX vendorizado disponível (ver nota em backend.go), o
+// caminho implementado aqui usa o endpoint de embeddings compatível com
+// OpenAI exposto pelo OpenRouter em vez de um modelo local ONNX MiniLM.
+
+const openRouterEmbeddingsURL = "https://openrouter.ai/api/v1/embeddings"
+
+func getEmbeddingModel() string {
+	if m := os.Getenv("OPENROUTER_EMBEDDING_MODEL"); m != "" {
+		return m
+	}
+	return "openai/text-embedding-3-small"
+}
+
+type embedVector []float64
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingDatum struct {
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+type embeddingResponse struct {
+	Data []embeddingDatum `json:"data"`
+}
+
+// embedTexts chama o endpoint de embeddings para um lote de textos, com
+// retry e backoff em erros transitórios (mesma política de #chunk0-1 usada
+// pelas demais chamadas de rede do pipeline), devolvendo um vetor por texto
+// na mesma ordem de entrada.
+func embedTexts(ctx context.Context, apiKey, model string, texts []string) ([]embedVector, error) {
+	bodyReq := embeddingRequest{Model: model, Input: texts}
+	payload, err := json.Marshal(bodyReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	var er embeddingResponse
+	err = withRetry(ctx, defaultRetryConfig(), func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, openRouterEmbeddingsURL, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("new request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("do request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBytes, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return &httpStatusError{Code: resp.StatusCode, Body: string(respBytes)}
+		}
+
+		er = embeddingResponse{}
+		if err := json.Unmarshal(respBytes, &er); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(er.Data) != len(texts) {
+		return nil, fmt.Errorf("esperava %d embeddings, recebeu %d", len(texts), len(er.Data))
+	}
+
+	out := make([]embedVector, len(texts))
+	for _, d := range er.Data {
+		out[d.Index] = d.Embedding
+	}
+	return out, nil
+}
+
+func cosineSimilarity(a, b embedVector) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// serviceCentroid é o vetor médio de embeddings associado a um serviço.
+type serviceCentroid struct {
+	ID     int
+	Name   string
+	Vector embedVector
+}
+
+// embeddingIndex é construído uma vez no início do benchmark e reutilizado
+// por todas as classificações em modo embed/hybrid.
+type embeddingIndex struct {
+	apiKey    string
+	model     string
+	centroids []serviceCentroid
+}
+
+// buildEmbeddingIndex calcula um centróide por serviço a partir do nome
+// cadastrado e, opcionalmente, de exemplos de treino (tipicamente as
+// linhas de intents_pre_loaded.csv).
+func buildEmbeddingIndex(ctx context.Context, apiKey, model string, trainingRows []csvRow) (*embeddingIndex, error) {
+	examplesByService := make(map[int][]string, len(services))
+	for _, s := range services {
+		examplesByService[s.ID] = append(examplesByService[s.ID], s.Name)
+	}
+	for _, row := range trainingRows {
+		examplesByService[row.ServiceID] = append(examplesByService[row.ServiceID], row.Intent)
+	}
+
+	var allTexts []string
+	var ownerOf []int
+	for _, s := range services {
+		for _, ex := range examplesByService[s.ID] {
+			allTexts = append(allTexts, ex)
+			ownerOf = append(ownerOf, s.ID)
+		}
+	}
+
+	vectors, err := embedTexts(ctx, apiKey, model, allTexts)
+	if err != nil {
+		return nil, fmt.Errorf("gerar embeddings do índice: %w", err)
+	}
+
+	sums := make(map[int]embedVector)
+	counts := make(map[int]int)
+	for i, v := range vectors {
+		id := ownerOf[i]
+		if sums[id] == nil {
+			sums[id] = make(embedVector, len(v))
+		}
+		for j := range v {
+			sums[id][j] += v[j]
+		}
+		counts[id]++
+	}
+
+	idx := &embeddingIndex{apiKey: apiKey, model: model}
+	for _, s := range services {
+		n := counts[s.ID]
+		if n == 0 {
+			continue
+		}
+		avg := sums[s.ID]
+		for j := range avg {
+			avg[j] /= float64(n)
+		}
+		idx.centroids = append(idx.centroids, serviceCentroid{ID: s.ID, Name: s.Name, Vector: avg})
+	}
+	return idx, nil
+}
+
+// scoredService é um candidato de classificação por embedding, com a
+// similaridade de cosseno contra o intent consultado.
+type scoredService struct {
+	ID    int
+	Name  string
+	Score float64
+}
+
+// classify devolve os serviços ordenados por similaridade de cosseno
+// decrescente contra o intent informado.
+func (idx *embeddingIndex) classify(ctx context.Context, intent string) ([]scoredService, error) {
+	vecs, err := embedTexts(ctx, idx.apiKey, idx.model, []string{intent})
+	if err != nil {
+		return nil, fmt.Errorf("embedding do intent: %w", err)
+	}
+	vec := vecs[0]
+
+	scored := make([]scoredService, 0, len(idx.centroids))
+	for _, c := range idx.centroids {
+		scored = append(scored, scoredService{ID: c.ID, Name: c.Name, Score: cosineSimilarity(vec, c.Vector)})
+	}
+	sort.Slice(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+	return scored, nil
+}
+
+// shortlistPrompt formata os top-k candidatos de embedding como uma lista
+// restrita a ser injetada no system prompt do LLM no modo hybrid.
+func shortlistPrompt(candidates []scoredService) string {
+	var b strings.Builder
+	b.WriteString("\nCandidatos mais prováveis (pré-selecionados por similaridade semântica):\n")
+	for _, c := range candidates {
+		fmt.Fprintf(&b, "%d: %s (score %.3f)\n", c.ID, c.Name, c.Score)
+	}
+	b.WriteString("Escolha preferencialmente entre os candidatos acima, mas pode retornar outro ID da lista completa se nenhum deles corresponder.\n")
+	return b.String()
+}
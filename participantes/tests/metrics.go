@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Subsistema de métricas por classe (precisão/recall/F1), matriz de
+// confusão e geração de relatórios arquiváveis, usado por runBatch para ir
+// além da acurácia agregada e expor confusões sistemáticas entre serviços.
+
+// classMetrics acumula verdadeiros-positivos, falsos-positivos e
+// falsos-negativos para um único serviço, somando as previsões de call#1 e
+// call#2.
+type classMetrics struct {
+	TP int
+	FP int
+	FN int
+}
+
+func (m classMetrics) Precision() float64 {
+	if m.TP+m.FP == 0 {
+		return 0
+	}
+	return float64(m.TP) / float64(m.TP+m.FP)
+}
+
+func (m classMetrics) Recall() float64 {
+	if m.TP+m.FN == 0 {
+		return 0
+	}
+	return float64(m.TP) / float64(m.TP+m.FN)
+}
+
+func (m classMetrics) F1() float64 {
+	p, r := m.Precision(), m.Recall()
+	if p+r == 0 {
+		return 0
+	}
+	return 2 * p * r / (p + r)
+}
+
+// record contabiliza uma previsão (expected, got) nas métricas por classe e
+// na matriz de confusão. got == 0 representa ausência de previsão válida
+// (erro de chamada ou de parse).
+func (s *batchStats) record(expected, got int) {
+	s.Confusion[expected][got]++
+	if got == expected {
+		m := s.ByClass[expected]
+		m.TP++
+		s.ByClass[expected] = m
+		return
+	}
+	fn := s.ByClass[expected]
+	fn.FN++
+	s.ByClass[expected] = fn
+	if got != 0 {
+		fp := s.ByClass[got]
+		fp.FP++
+		s.ByClass[got] = fp
+	}
+}
+
+// macroF1 é a média simples do F1 de cada classe vista (pesa todos os
+// serviços igualmente, independente de quantos casos cada um teve).
+func (s *batchStats) macroF1() float64 {
+	if len(s.ByClass) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, m := range s.ByClass {
+		sum += m.F1()
+	}
+	return sum / float64(len(s.ByClass))
+}
+
+// microF1 agrega TP/FP/FN de todas as classes antes de calcular
+// precisão/recall/F1 (pesa cada previsão igualmente, favorecendo classes
+// com mais casos).
+func (s *batchStats) microF1() (precision, recall, f1 float64) {
+	var agg classMetrics
+	for _, m := range s.ByClass {
+		agg.TP += m.TP
+		agg.FP += m.FP
+		agg.FN += m.FN
+	}
+	return agg.Precision(), agg.Recall(), agg.F1()
+}
+
+func (s *batchStats) printClassReport() {
+	fmt.Println("\nPrecisão / Recall / F1 por serviço:")
+	fmt.Printf("  %-3s %-6s %-6s %-6s  %s\n", "ID", "Prec", "Rec", "F1", "Nome")
+	for _, svc := range services {
+		m, ok := s.ByClass[svc.ID]
+		if !ok {
+			continue
+		}
+		fmt.Printf("  %-3d %-6.2f %-6.2f %-6.2f  %s\n", svc.ID, m.Precision(), m.Recall(), m.F1(), svc.Name)
+	}
+	microP, microR, microF1 := s.microF1()
+	fmt.Printf("  Macro F1: %.3f | Micro F1: %.3f (P=%.3f R=%.3f)\n", s.macroF1(), microF1, microP, microR)
+
+	fmt.Println("\nMatriz de confusão [esperado][obtido] (0 = sem previsão válida):")
+	header := "        " + fmt.Sprintf("%4s", "0")
+	for _, svc := range services {
+		header += fmt.Sprintf("%4d", svc.ID)
+	}
+	fmt.Println(header)
+	for _, exp := range services {
+		line := fmt.Sprintf("exp %2d:", exp.ID) + fmt.Sprintf("%4d", s.Confusion[exp.ID][0])
+		for _, got := range services {
+			line += fmt.Sprintf("%4d", s.Confusion[exp.ID][got.ID])
+		}
+		fmt.Println(line)
+	}
+}
+
+// ===== Relatório arquivável =====
+
+// rowReport é a previsão completa de uma linha, usada para compor o
+// relatório em disco (-report json|md|csv).
+type rowReport struct {
+	Idx        int    `json:"idx"`
+	Intent     string `json:"intent"`
+	ExpectedID int    `json:"expected_id"`
+	GotID1     int    `json:"got_id_1"`
+	GotID2     int    `json:"got_id_2"`
+	VotedID    int    `json:"voted_id"`
+	LatencyMs1 int64  `json:"latency_ms_1"`
+	LatencyMs2 int64  `json:"latency_ms_2"`
+	Agree      bool   `json:"agree"`
+	AllAgree   bool   `json:"all_agree"`
+}
+
+// batchReport é o relatório completo de uma rodada (PRE ou POS), pronto
+// para ser serializado e comparado entre execuções do benchmark.
+type batchReport struct {
+	Label         string      `json:"label"`
+	Model         string      `json:"model"`
+	Total         int         `json:"total"`
+	Accuracy1     float64     `json:"accuracy_1"`
+	Accuracy2     float64     `json:"accuracy_2"`
+	VotedAccuracy float64     `json:"voted_accuracy"`
+	AgreementRate float64     `json:"agreement_rate"`
+	MacroF1       float64     `json:"macro_f1"`
+	MicroF1       float64     `json:"micro_f1"`
+	CacheHitRate  float64     `json:"cache_hit_rate"`
+	Rows          []rowReport `json:"rows"`
+}
+
+var nonAlnum = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify normaliza um rótulo de rodada em um nome de arquivo seguro.
+func slugify(label string) string {
+	s := nonAlnum.ReplaceAllString(strings.ToLower(label), "-")
+	return strings.Trim(s, "-")
+}
+
+// writeReport grava o relatório de uma rodada em disco no formato pedido
+// (json, md ou csv), dentro de dir, nomeado a partir do label da rodada.
+func writeReport(dir, format string, r batchReport) error {
+	if format == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("criar diretório de relatórios: %w", err)
+	}
+	path := filepath.Join(dir, slugify(r.Label)+"."+format)
+
+	switch format {
+	case "json":
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("criar relatório: %w", err)
+		}
+		defer f.Close()
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("escrever relatório json: %w", err)
+		}
+
+	case "md":
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("criar relatório: %w", err)
+		}
+		defer f.Close()
+		fmt.Fprintf(f, "# %s\n\n", r.Label)
+		fmt.Fprintf(f, "Modelo: %s | Casos: %d | Acurácia #1: %.1f%% | Acurácia #2: %.1f%% | Acurácia votada: %.1f%%\n", r.Model, r.Total, r.Accuracy1, r.Accuracy2, r.VotedAccuracy)
+		fmt.Fprintf(f, "Macro F1: %.3f | Micro F1: %.3f | Cache hit rate: %.1f%% | Taxa de concordância: %.1f%%\n\n", r.MacroF1, r.MicroF1, r.CacheHitRate, r.AgreementRate)
+		fmt.Fprintln(f, "| idx | intent | expected | got#1 | got#2 | voted | lat#1(ms) | lat#2(ms) | agree | all_agree |")
+		fmt.Fprintln(f, "|---|---|---|---|---|---|---|---|---|---|")
+		for _, row := range r.Rows {
+			fmt.Fprintf(f, "| %d | %s | %d | %d | %d | %d | %d | %d | %v | %v |\n",
+				row.Idx, row.Intent, row.ExpectedID, row.GotID1, row.GotID2, row.VotedID, row.LatencyMs1, row.LatencyMs2, row.Agree, row.AllAgree)
+		}
+
+	case "csv":
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("criar relatório: %w", err)
+		}
+		defer f.Close()
+		w := csv.NewWriter(f)
+		defer w.Flush()
+		_ = w.Write([]string{"idx", "intent", "expected_id", "got_id_1", "got_id_2", "voted_id", "latency_ms_1", "latency_ms_2", "agree", "all_agree"})
+		for _, row := range r.Rows {
+			_ = w.Write([]string{
+				strconv.Itoa(row.Idx),
+				row.Intent,
+				strconv.Itoa(row.ExpectedID),
+				strconv.Itoa(row.GotID1),
+				strconv.Itoa(row.GotID2),
+				strconv.Itoa(row.VotedID),
+				strconv.FormatInt(row.LatencyMs1, 10),
+				strconv.FormatInt(row.LatencyMs2, 10),
+				strconv.FormatBool(row.Agree),
+				strconv.FormatBool(row.AllAgree),
+			})
+		}
+		w.Flush()
+		return w.Error()
+
+	default:
+		return fmt.Errorf("formato de relatório desconhecido: %q (use json, md ou csv)", format)
+	}
+
+	fmt.Printf("Relatório salvo em: %s\n", path)
+	return nil
+}
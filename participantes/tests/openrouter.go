@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
@@ -11,6 +12,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
@@ -28,6 +30,11 @@ Rodar:
     -pos intents_pos_loaded.csv
 
 Saída: relatório por arquivo (pre e pos), com latências e acurácia.
+
+Pipeline genérico, uma chamada por linha e sem o fluxo PRE/POS com
+votação/hybrid (ver Classifier em classifier.go), para qualquer backend
+incluindo o próprio openrouter:
+  go run main.go -backend openrouter|local|openai|ollama|rules -in arquivo.csv
 */
 
 const openRouterURL = "https://openrouter.ai/api/v1/chat/completions"
@@ -123,6 +130,65 @@ type caseResult struct {
 	RawOut2      string
 	Model        string
 	FinishReason string
+	Mode         string // "llm" ou "embed", qual caminho decidiu a classificação
+	VotedID      int    // resultado da votação por maioria entre as N amostras
+	AllAgree     bool   // true se todas as N amostras concordaram no mesmo ID
+	HasSecond    bool   // true se houve uma call#2 de fato (n > 1 amostras, ou modo embed)
+}
+
+// llmSample é uma única amostra do modelo (uma de N, em self-consistency
+// voting), com seu ID já parseado.
+type llmSample struct {
+	Raw string
+	Dur time.Duration
+	Err error
+	ID  int
+}
+
+// voteSamples aplica votação por maioria entre as amostras: o ID mais
+// votado vence, com empates desfeitos pela amostra de menor latência.
+// Amostras sem ID válido (erro ou parse) não concorrem à votação. allAgree
+// só é true se pelo menos uma amostra produziu um ID válido e todas as
+// amostras bateram nesse mesmo ID: linhas em que nenhuma amostra parseou
+// (falha total) contam como desacordo, não como concordância trivial.
+// Devolve (0, false) se nenhuma amostra produziu um ID válido.
+func voteSamples(samples []llmSample) (voted int, allAgree bool) {
+	type tally struct {
+		count   int
+		bestDur time.Duration
+	}
+	byID := make(map[int]*tally)
+	hasValid := false
+	allAgree = true
+	for _, s := range samples {
+		if s.ID != samples[0].ID {
+			allAgree = false
+		}
+		if s.ID == 0 {
+			continue
+		}
+		hasValid = true
+		if t, ok := byID[s.ID]; ok {
+			t.count++
+			if s.Dur < t.bestDur {
+				t.bestDur = s.Dur
+			}
+		} else {
+			byID[s.ID] = &tally{count: 1, bestDur: s.Dur}
+		}
+	}
+	if !hasValid {
+		allAgree = false
+	}
+
+	bestCount := -1
+	var bestDur time.Duration
+	for id, t := range byID {
+		if t.count > bestCount || (t.count == bestCount && t.bestDur < bestDur) {
+			voted, bestCount, bestDur = id, t.count, t.bestDur
+		}
+	}
+	return voted, allAgree
 }
 
 // ===== Prompt =====
@@ -146,14 +212,14 @@ func buildUserPrompt(intent string) string {
 // ===== HTTP =====
 var httpClient = &http.Client{Timeout: 45 * time.Second}
 
-func callOpenRouter(apiKey, model, sysPrompt, userPrompt string) (string, string, error) {
+func callOpenRouter(ctx context.Context, apiKey, model, sysPrompt, userPrompt string, temperature float64) (string, string, error) {
 	bodyReq := chatRequest{
 		Model: model,
 		Messages: []chatMessage{
 			{Role: "system", Content: sysPrompt},
 			{Role: "user", Content: userPrompt},
 		},
-		Temperature: 0,
+		Temperature: temperature,
 		MaxTokens:   8,
 	}
 	payload, err := json.Marshal(bodyReq)
@@ -161,7 +227,7 @@ func callOpenRouter(apiKey, model, sysPrompt, userPrompt string) (string, string
 		return "", "", fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, openRouterURL, bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, openRouterURL, bytes.NewReader(payload))
 	if err != nil {
 		return "", "", fmt.Errorf("new request: %w", err)
 	}
@@ -178,7 +244,7 @@ func callOpenRouter(apiKey, model, sysPrompt, userPrompt string) (string, string
 
 	respBytes, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", "", fmt.Errorf("status %d: %s", resp.StatusCode, string(respBytes))
+		return "", "", &httpStatusError{Code: resp.StatusCode, Body: string(respBytes)}
 	}
 
 	var cr chatResponse
@@ -275,80 +341,254 @@ type batchStats struct {
 	ByServiceSeen map[int]int // quantos casos daquele serviço
 	ByServiceOk1  map[int]int
 	ByServiceOk2  map[int]int
+
+	// ByClass acumula TP/FP/FN por serviço, somando as previsões de call#1
+	// e call#2. Confusion é a matriz [esperado][obtido], com a chave 0
+	// representando "sem previsão válida" (erro ou parse).
+	ByClass   map[int]classMetrics
+	Confusion map[int]map[int]int
+
+	// ByModeSeen/ByModeOk contam, por modo de classificação ("llm" ou
+	// "embed"), quantas linhas passaram por ele e quantas acertaram
+	// call#1 — usado para o relatório por modo do roteamento híbrido.
+	ByModeSeen map[string]int
+	ByModeOk   map[string]int
+
+	// OkVoted e AgreeRows suportam o self-consistency voting: quantas
+	// linhas a votação por maioria acertou, e quantas tiveram todas as N
+	// amostras concordando (proxy de confiança).
+	OkVoted   int
+	AgreeRows int
 }
 
 func newBatchStats() *batchStats {
+	confusion := make(map[int]map[int]int, len(services))
+	for _, s := range services {
+		confusion[s.ID] = make(map[int]int, len(services)+1)
+	}
 	return &batchStats{
 		ByServiceSeen: make(map[int]int),
 		ByServiceOk1:  make(map[int]int),
 		ByServiceOk2:  make(map[int]int),
+		ByClass:       make(map[int]classMetrics),
+		Confusion:     confusion,
+		ByModeSeen:    make(map[string]int),
+		ByModeOk:      make(map[string]int),
 	}
 }
 
-func runBatch(label string, rows []csvRow, apiKey, model string) {
+// callWithRetry executa uma chamada ao OpenRouter aplicando a política de
+// retry padrão (5xx, timeouts e deadline de contexto se beneficiam de nova
+// tentativa com backoff exponencial + jitter).
+func callWithRetry(ctx context.Context, apiKey, model, sys, user string, temperature float64) (string, time.Duration, error) {
+	var out string
+	t0 := time.Now()
+	err := withRetry(ctx, defaultRetryConfig(), func() error {
+		o, _, err := callOpenRouter(ctx, apiKey, model, sys, user, temperature)
+		if err == nil {
+			out = o
+		}
+		return err
+	})
+	return out, time.Since(t0), err
+}
+
+// cachedCall consulta `cache` (se houver) por (model, sys, intent) antes de
+// chamar o OpenRouter; em um acerto, devolve o valor cacheado com latência
+// zero. skipCache ignora o cache mesmo que configurado, usado pela segunda
+// chamada quando -no-cache-second-call está ativo, para não comprometer
+// medições de estabilidade entre call#1 e call#2. Amostras com
+// temperature != 0 sempre ignoram o cache: por definição variam a cada
+// chamada, então cachear a primeira resposta invalidaria o voting.
+func cachedCall(ctx context.Context, cache Cache, cstats *cacheStats, skipCache bool, apiKey, model, sys, intent string, temperature float64) (string, time.Duration, error) {
+	user := buildUserPrompt(intent)
+	if cache == nil || skipCache || temperature != 0 {
+		return callWithRetry(ctx, apiKey, model, sys, user, temperature)
+	}
+
+	key := cacheKey(model, sys, intent)
+	if val, ok := cache.Get(key); ok {
+		cstats.recordHit()
+		return val, 0, nil
+	}
+	cstats.recordMiss()
+
+	out, d, err := callWithRetry(ctx, apiKey, model, sys, user, temperature)
+	if err == nil {
+		cache.Set(key, out)
+	}
+	return out, d, err
+}
+
+// hybridConfig agrupa as opções do roteamento por embeddings (-mode).
+// Index é nil quando mode == "llm", caso em que runBatch se comporta como
+// antes da introdução do classificador por embeddings.
+type hybridConfig struct {
+	Mode   string // "llm", "embed" ou "hybrid"
+	Index  *embeddingIndex
+	Margin float64 // margem mínima do top-1 sobre o top-2 para decidir direto em modo hybrid
+}
+
+func runBatch(ctx context.Context, label string, rows []csvRow, apiKey, model string, workers int, limiter *tokenBucket, reportFormat, reportDir string, cache Cache, noCacheSecondCall bool, hybrid hybridConfig, samples int, voteTemperature float64) {
 	sys := buildSystemPrompt()
 	fmt.Printf("\n===== Rodada: %s =====\n", label)
-	fmt.Printf("Casos: %d | Modelo: %s\n", len(rows), model)
+	fmt.Printf("Casos: %d | Modelo: %s | Workers: %d\n", len(rows), model, workers)
 	fmt.Println("----------------------------------------------------------------------------------------------")
 	fmt.Printf("%-5s | %-5s | %-6s | %-6s | %-12s | %-12s | %s\n", "Idx", "Esper", "Got#1", "Got#2", "Lat#1(ms)", "Lat#2(ms)", "Intent")
 	fmt.Println("----------------------------------------------------------------------------------------------")
 
 	stats := newBatchStats()
 	stats.Total = len(rows)
+	rowReports := make([]rowReport, 0, len(rows))
+	cstats := &cacheStats{}
+
+	results := runWorkerPool(ctx, workers, len(rows), limiter, func(ctx context.Context, i int) (interface{}, error) {
+		row := rows[i]
+
+		rowSys := sys
+		var shortlist []scoredService
+		if hybrid.Index != nil {
+			scored, eerr := hybrid.Index.classify(ctx, row.Intent)
+			if eerr == nil && len(scored) > 0 {
+				topMargin := scored[0].Score
+				if len(scored) > 1 {
+					topMargin -= scored[1].Score
+				}
+				if hybrid.Mode == "embed" || (hybrid.Mode == "hybrid" && topMargin >= hybrid.Margin) {
+					out := strconv.Itoa(scored[0].ID)
+					return caseResult{
+						Idx: i, Intent: row.Intent, ExpectedID: row.ServiceID,
+						RawOut1: out, RawOut2: out, VotedID: scored[0].ID, AllAgree: true, Mode: "embed",
+					}, nil
+				}
+				if hybrid.Mode == "hybrid" {
+					shortlist = scored
+					if len(shortlist) > 3 {
+						shortlist = shortlist[:3]
+					}
+					rowSys = sys + shortlistPrompt(shortlist)
+				}
+			}
+		}
+
+		n := samples
+		if n < 1 {
+			n = 1
+		}
+		temp := 0.0
+		if n > 1 {
+			temp = voteTemperature
+		}
+
+		outs := make([]llmSample, n)
+		for s := 0; s < n; s++ {
+			skip := temp != 0 || (s == 1 && noCacheSecondCall)
+			raw, d, err := cachedCall(ctx, cache, cstats, skip, apiKey, model, rowSys, row.Intent, temp)
+			id := 0
+			if err == nil {
+				if pid, e := parseID(raw); e == nil {
+					id = pid
+				} else {
+					err = fmt.Errorf("parse: %w (raw=%q)", e, raw)
+				}
+			}
+			outs[s] = llmSample{Raw: raw, Dur: d, Err: err, ID: id}
+		}
+
+		cr := caseResult{
+			Idx:        i,
+			Intent:     row.Intent,
+			ExpectedID: row.ServiceID,
+			Dur1:       outs[0].Dur,
+			Err1:       outs[0].Err,
+			RawOut1:    outs[0].Raw,
+			Mode:       "llm",
+		}
+		if n > 1 {
+			cr.Dur2 = outs[1].Dur
+			cr.Err2 = outs[1].Err
+			cr.RawOut2 = outs[1].Raw
+			cr.HasSecond = true
+		}
+		cr.VotedID, cr.AllAgree = voteSamples(outs)
+		return cr, nil
+	})
 
 	for i, row := range rows {
 		stats.ByServiceSeen[row.ServiceID]++
 
-		user := buildUserPrompt(row.Intent)
+		cr, ok := results[i].Value.(caseResult)
+		if !ok {
+			// Linha não despachada (ex.: Ctrl-C) antes de concluir: runWorkerPool
+			// devolve Value nil e Err = ctx.Err(); trata como previsão falha.
+			cr = caseResult{Idx: i, Intent: row.Intent, ExpectedID: row.ServiceID, Err1: results[i].Err}
+		}
+		got1, got2 := 0, 0
 
-		// call #1
-		t1 := time.Now()
-		out1, usedModel1, err1 := callOpenRouter(apiKey, model, sys, user)
-		d1 := time.Since(t1)
-		got1 := 0
-		if err1 == nil {
-			if id, e := parseID(out1); e == nil {
+		if cr.Err1 == nil {
+			if id, e := parseID(cr.RawOut1); e == nil {
 				got1 = id
 				if got1 == row.ServiceID {
 					stats.Ok1++
 					stats.ByServiceOk1[row.ServiceID]++
 				}
 			} else {
-				err1 = fmt.Errorf("parse: %w (raw=%q)", e, out1)
+				cr.Err1 = fmt.Errorf("parse: %w (raw=%q)", e, cr.RawOut1)
 			}
 		}
-		_ = usedModel1 // mantido para futuro
 
-		// call #2
-		t2 := time.Now()
-		out2, usedModel2, err2 := callOpenRouter(apiKey, model, sys, user)
-		d2 := time.Since(t2)
-		got2 := 0
-		if err2 == nil {
-			if id, e := parseID(out2); e == nil {
+		if cr.HasSecond && cr.Err2 == nil {
+			if id, e := parseID(cr.RawOut2); e == nil {
 				got2 = id
 				if got2 == row.ServiceID {
 					stats.Ok2++
 					stats.ByServiceOk2[row.ServiceID]++
 				}
 			} else {
-				err2 = fmt.Errorf("parse: %w (raw=%q)", e, out2)
+				cr.Err2 = fmt.Errorf("parse: %w (raw=%q)", e, cr.RawOut2)
 			}
 		}
-		_ = usedModel2
 
-		stats.SumDur1 += d1
-		stats.SumDur2 += d2
+		stats.SumDur1 += cr.Dur1
+		stats.record(row.ServiceID, got1)
+		if cr.HasSecond {
+			stats.SumDur2 += cr.Dur2
+			stats.record(row.ServiceID, got2)
+		}
+
+		stats.ByModeSeen[cr.Mode]++
+		if got1 == row.ServiceID {
+			stats.ByModeOk[cr.Mode]++
+		}
+		if cr.VotedID == row.ServiceID {
+			stats.OkVoted++
+		}
+		if cr.AllAgree {
+			stats.AgreeRows++
+		}
+
+		rowReports = append(rowReports, rowReport{
+			Idx:        i + 1,
+			Intent:     row.Intent,
+			ExpectedID: row.ServiceID,
+			GotID1:     got1,
+			GotID2:     got2,
+			VotedID:    cr.VotedID,
+			LatencyMs1: cr.Dur1.Milliseconds(),
+			LatencyMs2: cr.Dur2.Milliseconds(),
+			Agree:      got1 == got2,
+			AllAgree:   cr.AllAgree,
+		})
 
 		fmt.Printf("%-5d | %-5d | %-6d | %-6d | %-12.2f | %-12.2f | %s\n",
-			i+1, row.ServiceID, got1, got2, float64(d1.Milliseconds()), float64(d2.Milliseconds()), row.Intent)
+			i+1, row.ServiceID, got1, got2, float64(cr.Dur1.Milliseconds()), float64(cr.Dur2.Milliseconds()), row.Intent)
 
-		if err1 != nil || err2 != nil {
-			if err1 != nil {
-				fmt.Printf("    erro#1: %s\n", trimErr(err1.Error()))
+		if cr.Err1 != nil || cr.Err2 != nil {
+			if cr.Err1 != nil {
+				fmt.Printf("    erro#1: %s\n", trimErr(cr.Err1.Error()))
 			}
-			if err2 != nil {
-				fmt.Printf("    erro#2: %s\n", trimErr(err2.Error()))
+			if cr.Err2 != nil {
+				fmt.Printf("    erro#2: %s\n", trimErr(cr.Err2.Error()))
 			}
 		}
 	}
@@ -358,9 +598,31 @@ func runBatch(label string, rows []csvRow, apiKey, model string) {
 	acc1 := percent(stats.Ok1, stats.Total)
 	acc2 := percent(stats.Ok2, stats.Total)
 
+	votedAcc := percent(stats.OkVoted, stats.Total)
+	agreementRate := percent(stats.AgreeRows, stats.Total)
+
 	fmt.Println("----------------------------------------------------------------------------------------------")
-	fmt.Printf("Acurácia #1: %.1f%%  | Acurácia #2: %.1f%%\n", acc1, acc2)
-	fmt.Printf("Latência média #1: %.2f ms | Latência média #2: %.2f ms\n", avg1, avg2)
+	fmt.Printf("Acurácia #1 (naive): %.1f%%  | Acurácia #2: %.1f%%  | Acurácia votada (N=%d): %.1f%%\n", acc1, acc2, samples, votedAcc)
+	fmt.Printf("Latência média #1: %.2f ms | Latência média #2: %.2f ms | Taxa de concordância: %.1f%%\n", avg1, avg2, agreementRate)
+	if cache != nil {
+		fmt.Printf("Cache: %d hits / %d misses (%.1f%% hit rate)\n", cstats.Hits, cstats.Misses, cstats.hitRate())
+	}
+	if hybrid.Mode != "llm" {
+		fmt.Println("\nPor modo de classificação (vistos / acurácia / chamadas ao LLM evitadas):")
+		for _, mode := range []string{"embed", "llm"} {
+			seen := stats.ByModeSeen[mode]
+			if seen == 0 {
+				continue
+			}
+			// Toda linha decidida em modo "embed" é uma chamada ao LLM que
+			// não aconteceu; linhas em modo "llm" não evitam nenhuma.
+			avoided := 0
+			if mode == "embed" {
+				avoided = seen
+			}
+			fmt.Printf("  %-6s: %4d / %5.1f%% / %4d\n", mode, seen, percent(stats.ByModeOk[mode], seen), avoided)
+		}
+	}
 
 	// per-service summary
 	fmt.Println("\nResumo por serviço (ID: vistos / ok#1 / ok#2 / nome):")
@@ -377,6 +639,26 @@ func runBatch(label string, rows []csvRow, apiKey, model string) {
 		ok2 := stats.ByServiceOk2[id]
 		fmt.Printf("  %2d: %3d / %3d / %3d  - %s\n", id, seen, ok1, ok2, serviceByID[id])
 	}
+
+	stats.printClassReport()
+
+	_, _, microF1 := stats.microF1()
+	report := batchReport{
+		Label:         label,
+		Model:         model,
+		Total:         stats.Total,
+		Accuracy1:     acc1,
+		Accuracy2:     acc2,
+		VotedAccuracy: votedAcc,
+		AgreementRate: agreementRate,
+		MacroF1:       stats.macroF1(),
+		MicroF1:       microF1,
+		CacheHitRate:  cstats.hitRate(),
+		Rows:          rowReports,
+	}
+	if err := writeReport(reportDir, reportFormat, report); err != nil {
+		fmt.Printf("aviso: falha ao gravar relatório: %v\n", err)
+	}
 }
 
 func percent(x, total int) float64 {
@@ -404,15 +686,118 @@ func main() {
 	log.SetFlags(0)
 
 	var prePath, posPath string
+	var workers int
+	var rps float64
+	var reportFormat, reportDir string
+	var cacheKind, cacheDir string
+	var cacheTTL time.Duration
+	var noCacheSecondCall bool
+	var mode string
+	var embedMargin float64
+	var samples int
+	var voteTemperature float64
+	var backend, inPath string
+	var localURL string
+	var openAIBaseURL, openAIAuthHeader, openAIModel string
+	var ollamaURL, ollamaModel string
+	var rulesFile string
 	flag.StringVar(&prePath, "pre", "intents_pre_loaded.csv", "caminho do CSV de pré-carregados")
 	flag.StringVar(&posPath, "pos", "intents_pos_loaded.csv", "caminho do CSV de pós-carregados")
+	flag.IntVar(&workers, "workers", 1, "número de requisições concorrentes ao OpenRouter")
+	flag.Float64Var(&rps, "rps", 0, "limite de requisições por segundo (0 = sem limite, recomendado para respeitar o QPS do OpenRouter)")
+	flag.StringVar(&reportFormat, "report", "", "grava um relatório arquivável da rodada em disco: json, md ou csv (vazio = desligado)")
+	flag.StringVar(&reportDir, "report-dir", "reports", "diretório onde salvar os relatórios de -report")
+	flag.StringVar(&cacheKind, "cache", "off", "cache de respostas do LLM: off, mem ou disk (disk grava um arquivo JSON por chave, não BoltDB/SQLite; ver nota em backend.go). Só tem efeito para -backend openrouter; local/openai/ollama/rules ainda não consultam o cache")
+	flag.StringVar(&cacheDir, "cache-dir", "", "diretório do cache em disco (padrão: ~/.cache/intent-bench/)")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "tempo de vida das entradas de cache (0 = sem expiração)")
+	flag.BoolVar(&noCacheSecondCall, "no-cache-second-call", false, "não consulta o cache na call#2, para preservar benchmarks de estabilidade")
+	flag.StringVar(&mode, "mode", "llm", "caminho de classificação: llm, embed ou hybrid")
+	flag.Float64Var(&embedMargin, "embed-margin", 0.08, "margem mínima do top-1 sobre o top-2 para o modo hybrid decidir direto por embedding")
+	flag.IntVar(&samples, "samples", 2, "número de amostras do LLM por linha, combinadas por votação majoritária quando > 1 (padrão 2: preserva as colunas Got#2/Lat#2 e a comparação de estabilidade já existentes, call#1 e call#2 em temperature=0)")
+	flag.Float64Var(&voteTemperature, "vote-temperature", -1, "temperature usada quando -samples > 1 (padrão: 0 se -samples <= 2, para não quebrar a comparação determinística legada de call#1/call#2; 0.3 se -samples > 2, modo de votação de verdade)")
+	flag.StringVar(&backend, "backend", "openrouter", "backend de classificação: openrouter, local, openai, ollama ou rules. -backend openrouter sem -in mantém o fluxo PRE/POS legado (call#2/votação/hybrid); qualquer backend combinado com -in roda o pipeline genérico de Classifier (uma chamada por linha)")
+	flag.StringVar(&inPath, "in", "", "CSV de entrada para o pipeline genérico de Classifier (mesmo formato de -pre/-pos; vazio = usa -pos). Definir -in roda o pipeline genérico mesmo com -backend openrouter")
+	flag.StringVar(&localURL, "local-url", "http://localhost:16081/api/find-service", "URL do endpoint /api/find-service para -backend local")
+	flag.StringVar(&openAIBaseURL, "openai-base-url", "https://api.openai.com/v1", "Base URL para -backend openai (ou qualquer endpoint compatível)")
+	flag.StringVar(&openAIAuthHeader, "openai-auth-header", "Authorization", "Header de autenticação HTTP para -backend openai")
+	flag.StringVar(&openAIModel, "openai-model", "", "Modelo para -backend openai (vazio = usa OPENROUTER_MODEL/getModel)")
+	flag.StringVar(&ollamaURL, "ollama-url", "http://localhost:11434", "Base URL do servidor Ollama para -backend ollama")
+	flag.StringVar(&ollamaModel, "ollama-model", "llama3", "Modelo local para -backend ollama")
+	flag.StringVar(&rulesFile, "rules-file", "assets/rules.yaml", "Arquivo de regras determinísticas para -backend rules")
 	flag.Parse()
 
+	if voteTemperature < 0 {
+		voteTemperature = 0
+		// samples == 2 é o invocation legado (call#1/call#2 determinísticos,
+		// documentado no topo deste arquivo como "Temperature = 0."); só a
+		// votação de verdade (samples > 2) ganha o default de exploração.
+		if samples > 2 {
+			voteTemperature = 0.3
+		}
+	}
+
 	apiKey := os.Getenv("OPENROUTER_API_KEY")
+	model := getModel()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	limiter := newTokenBucket(rps)
+
+	// -in explícito (mesmo com -backend openrouter, o default) escolhe o
+	// pipeline genérico de Classifier (uma chamada por linha, sem
+	// call#2/votação/hybrid): é o que unifica "-backend openrouter|local|
+	// openai|ollama|rules -in arquivo.csv" atrás de runBackendBatch. Sem
+	// -in, -backend openrouter continua caindo no fluxo PRE/POS legado
+	// abaixo, para não quebrar a invocação documentada no topo do arquivo.
+	if backend != "openrouter" || inPath != "" {
+		path := inPath
+		if path == "" {
+			path = posPath
+		}
+		rows, err := loadCSV(path)
+		if err != nil {
+			log.Fatalf("Falha ao ler %s: %v", path, err)
+		}
+
+		// Só o backend "openrouter" consulta o Cache (via
+		// newOpenRouterClassifier); local/openai/ollama/rules ainda não têm
+		// essa integração, então -cache é ignorado para eles e a linha
+		// "Cache: ..." de runBackendBatch fica suprimida (cache == nil) em
+		// vez de anunciar hits/misses que nunca acontecem.
+		var cache Cache
+		if backend == "openrouter" {
+			var err error
+			cache, err = newCache(cacheKind, cacheDir, cacheTTL)
+			if err != nil {
+				log.Fatalf("Falha ao iniciar cache: %v", err)
+			}
+		}
+
+		cstats := &cacheStats{}
+		classifier, err := buildClassifier(backend, backendOptions{
+			apiKey: apiKey, model: model,
+			localURL:         localURL,
+			openAIBaseURL:    openAIBaseURL,
+			openAIAuthHeader: openAIAuthHeader,
+			openAIModel:      openAIModel,
+			ollamaURL:        ollamaURL,
+			ollamaModel:      ollamaModel,
+			rulesFile:        rulesFile,
+			cache:            cache,
+			cstats:           cstats,
+		})
+		if err != nil {
+			log.Fatalf("Falha ao construir backend %q: %v", backend, err)
+		}
+
+		runBackendBatch(ctx, fmt.Sprintf("%s (%s)", backend, path), rows, classifier, workers, limiter, reportFormat, reportDir, cache, cstats)
+		return
+	}
+
 	if apiKey == "" {
 		log.Fatal("Defina OPENROUTER_API_KEY no ambiente")
 	}
-	model := getModel()
 
 	preRows, err := loadCSV(prePath)
 	if err != nil {
@@ -423,8 +808,22 @@ func main() {
 		log.Fatalf("Falha ao ler %s: %v", posPath, err)
 	}
 
+	cache, err := newCache(cacheKind, cacheDir, cacheTTL)
+	if err != nil {
+		log.Fatalf("Falha ao iniciar cache: %v", err)
+	}
+
+	hybrid := hybridConfig{Mode: mode, Margin: embedMargin}
+	if mode != "llm" {
+		idx, err := buildEmbeddingIndex(ctx, apiKey, getEmbeddingModel(), preRows)
+		if err != nil {
+			log.Fatalf("Falha ao construir índice de embeddings: %v", err)
+		}
+		hybrid.Index = idx
+	}
+
 	// Rodada 1: PRE
-	runBatch("PRE ("+prePath+")", preRows, apiKey, model)
+	runBatch(ctx, "PRE ("+prePath+")", preRows, apiKey, model, workers, limiter, reportFormat, reportDir, cache, noCacheSecondCall, hybrid, samples, voteTemperature)
 	// Rodada 2: POS
-	runBatch("POS ("+posPath+")", posRows, apiKey, model)
+	runBatch(ctx, "POS ("+posPath+")", posRows, apiKey, model, workers, limiter, reportFormat, reportDir, cache, noCacheSecondCall, hybrid, samples, voteTemperature)
 }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Subsistema de execução concorrente usado pelos runners (runBackendBatch e
+// runBatch) para disparar chamadas HTTP em paralelo sem estourar os limites
+// de QPS dos backends e sem perder a ordem original das linhas do CSV.
+
+// ===== Rate limiter (token bucket) =====
+
+// tokenBucket limita a taxa de saída de requisições usando o algoritmo de
+// balde de fichas: fichas são repostas continuamente a `rate` por segundo e
+// cada chamada a Wait bloqueia até haver uma ficha disponível ou o contexto
+// ser cancelado. Um limiter nil (rps <= 0) significa "sem limite".
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func newTokenBucket(rps float64) *tokenBucket {
+	if rps <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		tokens:   rps,
+		capacity: rps,
+		rate:     rps,
+		last:     time.Now(),
+	}
+}
+
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = minFloat(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ===== Retry com backoff exponencial + jitter =====
+
+// retryConfig descreve uma política de novas tentativas para chamadas de
+// rede transitoriamente instáveis (5xx, timeouts, deadline de contexto).
+type retryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	Factor      float64
+	CapDelay    time.Duration
+}
+
+func defaultRetryConfig() retryConfig {
+	return retryConfig{
+		MaxAttempts: 4,
+		BaseDelay:   250 * time.Millisecond,
+		Factor:      2,
+		CapDelay:    10 * time.Second,
+	}
+}
+
+// httpStatusError carrega o status HTTP retornado por um backend,
+// permitindo que withRetry distinga erros transitórios (5xx) de erros
+// permanentes (4xx, payload inválido etc.).
+type httpStatusError struct {
+	Code int
+	Body string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.Code, e.Body)
+}
+
+// isRetryable decide se um erro deve ser tentado novamente: 5xx, timeout de
+// rede ou deadline de contexto excedido.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500
+	}
+	return false
+}
+
+// withRetry executa fn até cfg.MaxAttempts vezes, aplicando backoff
+// exponencial com jitter entre tentativas. Para cedo se o erro não for
+// retryable ou se o contexto for cancelado.
+func withRetry(ctx context.Context, cfg retryConfig, fn func() error) error {
+	var lastErr error
+	delay := cfg.BaseDelay
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		lastErr = fn()
+		if lastErr == nil {
+			return nil
+		}
+		if !isRetryable(lastErr) || attempt == cfg.MaxAttempts {
+			return lastErr
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(delay) + 1))
+		wait := delay + jitter/2
+		if wait > cfg.CapDelay {
+			wait = cfg.CapDelay
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		delay = time.Duration(float64(delay) * cfg.Factor)
+		if delay > cfg.CapDelay {
+			delay = cfg.CapDelay
+		}
+	}
+	return lastErr
+}
+
+// ===== Worker pool com reordenação =====
+
+// orderedResult associa o índice original da linha ao valor produzido,
+// permitindo reconstruir a ordem de entrada mesmo com processamento
+// concorrente.
+type orderedResult struct {
+	Idx   int
+	Value interface{}
+	Err   error
+}
+
+// runWorkerPool dispatcha os índices [0, items) para `workers` goroutines,
+// respeitando `limiter` (pode ser nil) antes de cada chamada a `process`, e
+// devolve os resultados já reordenados por índice usando um pequeno buffer
+// de reordenação. O cancelamento de `ctx` (ex.: Ctrl-C) interrompe o
+// despacho de novos itens e propaga context.Canceled para os pendentes.
+func runWorkerPool(ctx context.Context, workers, items int, limiter *tokenBucket, process func(ctx context.Context, idx int) (interface{}, error)) []orderedResult {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	resultsCh := make(chan orderedResult, items)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if err := limiter.Wait(ctx); err != nil {
+					resultsCh <- orderedResult{Idx: idx, Err: err}
+					continue
+				}
+				val, err := process(ctx, idx)
+				resultsCh <- orderedResult{Idx: idx, Value: val, Err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := 0; i < items; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- i:
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultsCh)
+	}()
+
+	ordered := make([]orderedResult, items)
+	seen := make([]bool, items)
+	for r := range resultsCh {
+		ordered[r.Idx] = r
+		seen[r.Idx] = true
+	}
+	for i, ok := range seen {
+		if !ok {
+			ordered[i] = orderedResult{Idx: i, Err: ctx.Err()}
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Classificador determinístico por palavra-chave/regex, usado pelo backend
+// -backend rules como baseline de custo zero (sem chamada de rede). As
+// regras são carregadas de um arquivo no formato:
+//
+//   - id: 3
+//     patterns:
+//   - "segunda via de fatura"
+//   - "fatura"
+//   - id: 9
+//     patterns:
+//   - "desbloque"
+//
+// Este é um subconjunto mínimo de YAML (lista de mapas com uma chave
+// "patterns" aninhada), lido à mão em vez de gopkg.in/yaml.v3 (ver nota em
+// backend.go). O formato acima cobre o que o backend rules precisa: uma
+// lista de (id, padrões).
+type classifierRule struct {
+	ID       int
+	Patterns []*regexp.Regexp
+}
+
+// loadRulesFile lê um arquivo de regras no formato descrito acima.
+func loadRulesFile(path string) ([]classifierRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("abrir arquivo de regras: %w", err)
+	}
+	defer f.Close()
+
+	var rules []classifierRule
+	var cur *classifierRule
+	inPatterns := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		trimmed := strings.TrimSpace(scanner.Text())
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- id:") {
+			if cur != nil {
+				rules = append(rules, *cur)
+			}
+			idStr := strings.TrimSpace(strings.TrimPrefix(trimmed, "- id:"))
+			id, err := strconv.Atoi(idStr)
+			if err != nil {
+				return nil, fmt.Errorf("id de regra inválido %q: %w", idStr, err)
+			}
+			cur = &classifierRule{ID: id}
+			inPatterns = false
+			continue
+		}
+
+		if trimmed == "patterns:" {
+			inPatterns = true
+			continue
+		}
+
+		if inPatterns && strings.HasPrefix(trimmed, "-") {
+			if cur == nil {
+				return nil, fmt.Errorf("pattern sem \"- id:\" correspondente: %q", trimmed)
+			}
+			pat := strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "-")), `"`)
+			re, err := regexp.Compile("(?i)" + pat)
+			if err != nil {
+				return nil, fmt.Errorf("padrão inválido %q: %w", pat, err)
+			}
+			cur.Patterns = append(cur.Patterns, re)
+			continue
+		}
+	}
+	if cur != nil {
+		rules = append(rules, *cur)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ler arquivo de regras: %w", err)
+	}
+	return rules, nil
+}
+
+// rulesClassifier escolhe o serviço da primeira regra cujo padrão bate com
+// o intent, na ordem em que aparecem no arquivo. Não bater com nenhuma
+// regra é um erro (ID 0), já que este backend não tem fallback de rede.
+type rulesClassifier struct {
+	rules []classifierRule
+}
+
+func newRulesClassifier(path string) (*rulesClassifier, error) {
+	rules, err := loadRulesFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rulesClassifier{rules: rules}, nil
+}
+
+func (c *rulesClassifier) Classify(_ context.Context, intent string) (int, string, error) {
+	for _, r := range c.rules {
+		for _, re := range r.Patterns {
+			if re.MatchString(intent) {
+				return r.ID, re.String(), nil
+			}
+		}
+	}
+	return 0, "", fmt.Errorf("nenhuma regra bateu com o intent: %q", intent)
+}